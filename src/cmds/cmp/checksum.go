@@ -0,0 +1,191 @@
+// Copyright 2013 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"log"
+	"os"
+	"runtime"
+	"sort"
+	"sync"
+
+	"github.com/u-root/u-root/src/pkg/cmpcore"
+	"golang.org/x/crypto/blake2b"
+	"golang.org/x/sync/semaphore"
+)
+
+// newHasher returns a fresh hash.Hash for the named checksum algorithm.
+func newHasher(algo string) (hash.Hash, error) {
+	switch algo {
+	case "crc32":
+		return crc32.NewIEEE(), nil
+	case "sha256":
+		return sha256.New(), nil
+	case "blake2b":
+		return blake2b.New256(nil)
+	default:
+		return nil, fmt.Errorf("unknown checksum algorithm %q (want crc32, sha256, or blake2b)", algo)
+	}
+}
+
+// blockDigest hashes the block at [off, off+size) of f, using its own
+// *os.File handle so it can run concurrently with other blocks.
+func blockDigest(name string, algo string, off, size int64) ([]byte, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	h, err := newHasher(algo)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := f.Seek(off, io.SeekStart); err != nil {
+		return nil, err
+	}
+	if _, err := io.CopyN(h, f, size); err != nil && err != io.EOF {
+		return nil, err
+	}
+	return h.Sum(nil), nil
+}
+
+// checksumMode implements -c: it compares two same-length regular files by
+// hashing fixed-size blocks in parallel and reporting only the blocks whose
+// digests differ, narrowing each mismatch to a byte offset with a second,
+// targeted pass. It falls back to the streaming comparison used by
+// twoFileMode whenever the files aren't both seekable regular files of the
+// same length (after accounting for the offset arguments, if any).
+func checksumMode(fnames [2]string, offset [2]int64, algo string, blockSize int64) int {
+	if blockSize <= 0 {
+		log.Fatalf("invalid -B %d: block size must be positive", blockSize)
+	}
+
+	var sizes [2]int64
+	for i, name := range fnames {
+		if name == "-" {
+			return twoFileMode(fnames, offset)
+		}
+		fi, err := os.Stat(name)
+		if err != nil {
+			log.Fatalf("Failed to stat %s: %v", name, err)
+		}
+		if !fi.Mode().IsRegular() {
+			return twoFileMode(fnames, offset)
+		}
+		sizes[i] = fi.Size() - offset[i]
+	}
+	if sizes[0] != sizes[1] || sizes[0] < 0 {
+		return twoFileMode(fnames, offset)
+	}
+
+	size := sizes[0]
+	numBlocks := (size + blockSize - 1) / blockSize
+	if numBlocks == 0 {
+		return 0
+	}
+
+	sem := semaphore.NewWeighted(int64(runtime.GOMAXPROCS(0)))
+	ctx := context.Background()
+
+	var mu sync.Mutex
+	var mismatched []int64
+	var wg sync.WaitGroup
+	var firstErr error
+
+	for i := int64(0); i < numBlocks; i++ {
+		off := i * blockSize
+		n := blockSize
+		if off+n > size {
+			n = size - off
+		}
+
+		if err := sem.Acquire(ctx, 1); err != nil {
+			log.Fatalf("%v", err)
+		}
+		wg.Add(1)
+		go func(idx, off, n int64) {
+			defer wg.Done()
+			defer sem.Release(1)
+
+			da, err := blockDigest(fnames[0], algo, offset[0]+off, n)
+			if err != nil {
+				mu.Lock()
+				if firstErr == nil {
+					firstErr = err
+				}
+				mu.Unlock()
+				return
+			}
+			db, err := blockDigest(fnames[1], algo, offset[1]+off, n)
+			if err != nil {
+				mu.Lock()
+				if firstErr == nil {
+					firstErr = err
+				}
+				mu.Unlock()
+				return
+			}
+			if !bytesEqual(da, db) {
+				mu.Lock()
+				mismatched = append(mismatched, idx)
+				mu.Unlock()
+			}
+		}(i, off, n)
+	}
+	wg.Wait()
+
+	if firstErr != nil {
+		log.Fatalf("%v", firstErr)
+	}
+	if len(mismatched) == 0 {
+		return 0
+	}
+	if *silent {
+		return 1
+	}
+
+	sort.Slice(mismatched, func(i, j int) bool { return mismatched[i] < mismatched[j] })
+
+	for _, idx := range mismatched {
+		off := idx * blockSize
+		charNo, err := narrowBlock(fnames, offset, off)
+		if err != nil {
+			log.Fatalf("%v", err)
+		}
+		fmt.Fprintf(os.Stderr, "%s %s differ: block %d (offset %d): first differing byte at %d\n",
+			fnames[0], fnames[1], idx, off, charNo)
+	}
+	return 1
+}
+
+// narrowBlock re-compares fnames byte-by-byte starting at block offset off
+// within the comparison region (i.e. relative to offset) and returns the
+// 1-based byte position of the first difference, relative to offset, to
+// match the numbering twoFileMode reports.
+func narrowBlock(fnames [2]string, offset [2]int64, off int64) (int64, error) {
+	f0, err := openFile(fnames[0])
+	if err != nil {
+		return 0, err
+	}
+	defer f0.Close()
+	f1, err := openFile(fnames[1])
+	if err != nil {
+		return 0, err
+	}
+	defer f1.Close()
+
+	r, err := cmpcore.Compare(f0, f1, offset[0]+off, offset[1]+off, cmpcore.Options{})
+	if err != nil {
+		return 0, err
+	}
+	return off + r.First.CharNo, nil
+}
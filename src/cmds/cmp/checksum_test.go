@@ -0,0 +1,78 @@
+// Copyright 2013 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func writeTempFile(t *testing.T, dir, name string, data []byte) string {
+	t.Helper()
+	p := filepath.Join(dir, name)
+	if err := os.WriteFile(p, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestChecksumModeEqualFiles(t *testing.T) {
+	dir := t.TempDir()
+	data := []byte("abcdefghijklmnopqrstuvwxyz")
+	a := writeTempFile(t, dir, "a", data)
+	b := writeTempFile(t, dir, "b", data)
+	if got := checksumMode([2]string{a, b}, [2]int64{0, 0}, "crc32", 4); got != 0 {
+		t.Errorf("checksumMode() = %d, want 0 for identical files", got)
+	}
+}
+
+func TestChecksumModeOneMismatchedBlock(t *testing.T) {
+	dir := t.TempDir()
+	a := writeTempFile(t, dir, "a", []byte("aaaabbbbcccc"))
+	b := writeTempFile(t, dir, "b", []byte("aaaaXXXXcccc"))
+	if got := checksumMode([2]string{a, b}, [2]int64{0, 0}, "crc32", 4); got != 1 {
+		t.Errorf("checksumMode() = %d, want 1 for one mismatched block", got)
+	}
+}
+
+func TestChecksumModeWithOffset(t *testing.T) {
+	dir := t.TempDir()
+	a := writeTempFile(t, dir, "a", []byte("PREFIXaaaabbbbcccc"))
+	b := writeTempFile(t, dir, "b", []byte("aaaabbbbcccc"))
+	if got := checksumMode([2]string{a, b}, [2]int64{6, 0}, "crc32", 4); got != 0 {
+		t.Errorf("checksumMode() with offsets = %d, want 0 for matching tails", got)
+	}
+}
+
+func TestChecksumModeOddBlockSize(t *testing.T) {
+	dir := t.TempDir()
+	a := writeTempFile(t, dir, "a", []byte("aaaabbbbcccc"))
+	b := writeTempFile(t, dir, "b", []byte("aaaabXbbcccc"))
+	if got := checksumMode([2]string{a, b}, [2]int64{0, 0}, "crc32", 5); got != 1 {
+		t.Errorf("checksumMode() with block size 5 = %d, want 1", got)
+	}
+}
+
+// TestChecksumModeInvalidBlockSizeFatal verifies -B 0 is rejected via
+// log.Fatalf rather than panicking or silently misreporting. It re-execs
+// this test binary, since checksumMode calls os.Exit(1) through log.Fatalf.
+func TestChecksumModeInvalidBlockSizeFatal(t *testing.T) {
+	if os.Getenv("CMP_TEST_HELPER_INVALID_BLOCKSIZE") == "1" {
+		dir := t.TempDir()
+		a := writeTempFile(t, dir, "a", []byte("x"))
+		b := writeTempFile(t, dir, "b", []byte("x"))
+		checksumMode([2]string{a, b}, [2]int64{0, 0}, "crc32", 0)
+		return
+	}
+
+	cmd := exec.Command(os.Args[0], "-test.run=TestChecksumModeInvalidBlockSizeFatal")
+	cmd.Env = append(os.Environ(), "CMP_TEST_HELPER_INVALID_BLOCKSIZE=1")
+	err := cmd.Run()
+	if ee, ok := err.(*exec.ExitError); !ok || ee.Success() {
+		t.Fatalf("checksumMode(blockSize=0) exited with %v, want a non-zero exit", err)
+	}
+}
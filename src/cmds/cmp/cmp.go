@@ -5,12 +5,33 @@
 /*
 Cmp compares the two files and prints a message if the contents differ.
 
-cmp [ –lLs ] file1 file2 [ offset1 [ offset2 ] ]
+cmp [ –lLsdbr ] [ –exclude pattern ] [ –brief ] [ –c algo ] [ –B size ] file1 file2 [ offset1 [ offset2 ] ]
 
 The options are:
 	–l    Print the byte number (decimal) and the differing bytes (octal) for each difference.
 	–L    Print the line number of the first differing byte.
 	–s    Print nothing for differing files, but set the exit status.
+	–d    Print a full diff instead of stopping at the first difference:
+	      a unified text diff for text files, or a side-by-side hexdump
+	      for binary files.
+	–b    Force binary (hexdump) diff output with -d, instead of
+	      auto-detecting by scanning for NUL bytes.
+	–r    Recursively compare the two directory trees named by file1 and
+	      file2, reporting files that differ, exist on only one side, or
+	      have mismatched modes, sizes, or symlink targets.
+	–exclude pattern
+	      Skip paths matching the glob pattern (may be repeated).
+	–brief
+	      With -r, report only the names of differing paths, not offsets.
+	–c algo
+	      Compare by hashing fixed-size blocks instead of byte-by-byte,
+	      using the given algorithm (crc32, sha256, or blake2b). Only
+	      the indexes of mismatching blocks are reported, each narrowed
+	      to a byte offset with a second pass. Falls back to the normal
+	      comparison if the files aren't both seekable regular files of
+	      the same length.
+	–B size
+	      Block size in bytes for -c (default 1 MiB).
 
 -If offsets are given, comparison starts at the designated byte position of the corresponding file.
 -Offsets that begin with 0x are hexadecimal; with 0, octal; with anything else, decimal.
@@ -19,34 +40,41 @@ The options are:
 package main
 
 import (
-	"bufio"
 	"flag"
-	"io"
+	"fmt"
+	"io/ioutil"
 	"log"
 	"os"
 	"strconv"
+
+	"github.com/u-root/u-root/src/pkg/cmpcore"
+	"github.com/u-root/u-root/src/pkg/cmpdiff"
 )
 
-var long = flag.Bool("l", false, "print the byte number (decimal) and the differing bytes (hexadecimal) for each difference")
-var line = flag.Bool("L", false, "print the line number of the first differing byte")
-var silent = flag.Bool("s", false, "print nothing for differing files, but set the exit status")
+var (
+	long      = flag.Bool("l", false, "print the byte number (decimal) and the differing bytes (hexadecimal) for each difference")
+	line      = flag.Bool("L", false, "print the line number of the first differing byte")
+	silent    = flag.Bool("s", false, "print nothing for differing files, but set the exit status")
+	diff      = flag.Bool("d", false, "print a full diff instead of stopping at the first difference")
+	binary    = flag.Bool("b", false, "treat the files as binary for -d, instead of auto-detecting")
+	recurse   = flag.Bool("r", false, "recursively compare two directory trees")
+	brief     = flag.Bool("brief", false, "with -r, report only differing names, not offsets")
+	checksum  = flag.String("c", "", "compare using block checksums (crc32, sha256, or blake2b) instead of byte-by-byte")
+	blockSize = flag.Int64("B", 1<<20, "block size in bytes for -c")
+	exclude   excludeList
+)
 
-func emit(rs io.ReadSeeker, c chan byte, offset int64) error {
-	if offset > 0 {
-		if _, err := rs.Seek(offset, 0); err != nil {
-			log.Fatalf("%v", err)
-		}
-	}
+func init() {
+	flag.Var(&exclude, "exclude", "glob pattern to exclude from -r comparison (may be repeated)")
+}
 
-	b := bufio.NewReader(rs)
-	for {
-		b, err := b.ReadByte()
-		if err != nil {
-			close(c)
-			return err
-		}
-		c <- b
-	}
+// excludeList collects repeated -exclude glob patterns.
+type excludeList []string
+
+func (e *excludeList) String() string { return fmt.Sprint([]string(*e)) }
+func (e *excludeList) Set(pattern string) error {
+	*e = append(*e, pattern)
+	return nil
 }
 
 func openFile(name string) (*os.File, error) {
@@ -62,14 +90,119 @@ func openFile(name string) (*os.File, error) {
 	return f, err
 }
 
+// readAll reads the full contents of name starting at offset, for use by
+// the -d diff mode, which needs both files in memory to compute a diff.
+func readAll(name string, offset int64) ([]byte, error) {
+	f, err := openFile(name)
+	if err != nil {
+		return nil, err
+	}
+	if name != "-" {
+		defer f.Close()
+	}
+	if offset > 0 {
+		if _, err := f.Seek(offset, 0); err != nil {
+			return nil, err
+		}
+	}
+	return ioutil.ReadAll(f)
+}
+
+func diffMode(fnames [2]string, offset [2]int64) int {
+	a, err := readAll(fnames[0], offset[0])
+	if err != nil {
+		log.Fatalf("Failed to read %s: %v", fnames[0], err)
+	}
+	b, err := readAll(fnames[1], offset[1])
+	if err != nil {
+		log.Fatalf("Failed to read %s: %v", fnames[1], err)
+	}
+
+	if bytesEqual(a, b) {
+		return 0
+	}
+	if *silent {
+		return 1
+	}
+
+	if *binary || cmpdiff.IsBinary(a) || cmpdiff.IsBinary(b) {
+		fmt.Print(cmpdiff.HexDiff(a, b))
+	} else {
+		fmt.Print(cmpdiff.TextDiff(fnames[0], fnames[1], a, b))
+	}
+	return 1
+}
+
+func bytesEqual(a, b []byte) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// twoFileMode performs the classic Plan 9 cmp comparison of two streams,
+// formatting the result the way the CLI always has.
+func twoFileMode(fnames [2]string, offset [2]int64) int {
+	var f [2]*os.File
+	for i := 0; i < 2; i++ {
+		var err error
+		f[i], err = openFile(fnames[i])
+		if err != nil {
+			log.Fatalf("Failed to open %s: %v", fnames[i], err)
+		}
+	}
+
+	r, err := cmpcore.Compare(f[0], f[1], offset[0], offset[1], cmpcore.Options{Line: *line})
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+	if r.Equal {
+		return 0
+	}
+	if *silent {
+		return 1
+	}
+
+	if r.ShortSide != 0 {
+		short := fnames[0]
+		if r.ShortSide == 2 {
+			short = fnames[1]
+		}
+		fmt.Fprintf(os.Stderr, "EOF on %s\n", short)
+		return 1
+	}
+
+	if *line {
+		fmt.Fprintf(os.Stderr, "%s %s differ: char %d line %d\n", fnames[0], fnames[1], r.First.CharNo, r.First.LineNo)
+		return 1
+	}
+	if *long {
+		fmt.Fprintf(os.Stderr, "%8d %#.2o %#.2o\n", r.First.CharNo, r.First.ValA, r.First.ValB)
+		return 1
+	}
+	fmt.Fprintf(os.Stderr, "%s %s differ: char %d\n", fnames[0], fnames[1], r.First.CharNo)
+	return 1
+}
+
 func main() {
 	flag.Parse()
 	var offset [2]int64
-	var f *os.File
 	var err error
 
 	fnames := flag.Args()
 
+	if *recurse {
+		if len(fnames) != 2 {
+			log.Fatalf("-r expects exactly two directory arguments, got %d", len(fnames))
+		}
+		os.Exit(recurseMode(fnames[0], fnames[1]))
+	}
+
 	switch len(fnames) {
 	case 2:
 	case 3:
@@ -93,52 +226,13 @@ func main() {
 		log.Fatalf("expected two filenames (and one to two optional offsets), got %d", len(fnames))
 	}
 
-	c := make([]chan byte, 2)
+	if *diff {
+		os.Exit(diffMode([2]string{fnames[0], fnames[1]}, offset))
+	}
 
-	for i := 0; i < 2; i++ {
-		if f, err = openFile(fnames[i]); err != nil {
-			log.Fatalf("Failed to open %s: %v", fnames[i], err)
-		}
-		c[i] = make(chan byte, 8192)
-		go emit(f, c[i], offset[i])
-	}
-
-	lineno, charno := int64(1), int64(1)
-	var b1, b2 byte
-	for {
-		b1 = <-c[0]
-		b2 = <-c[1]
-
-		if b1 != b2 {
-			if *silent {
-				os.Exit(1)
-			}
-			if *line {
-				fmt.Fprintf(os.Stderr, "%s %s differ: char %d line %d\n", fnames[0], fnames[1], charno, lineno)
-				os.Exit(1)
-			}
-			if *long {
-				if b1 == '\u0000' {
-					fmt.Fprintf(os.Stderr, "EOF on %s\n", fnames[0])
-					os.Exit(1)
-				}
-				if b2 == '\u0000' {
-					fmt.Fprintf(os.Stderr, "EOF on %s\n", fnames[1])
-					os.Exit(1)
-				}
-				fmt.Fprintf(os.Stderr, "%8d %#.2o %#.2o\n", charno, b1, b2)
-				goto skip
-			}
-			fmt.Fprintf(os.Stderr, "%s %s differ: char %d\n", fnames[0], fnames[1], charno)
-			os.Exit(1)
-		}
-	skip:
-		charno++
-		if b1 == '\n' {
-			lineno++
-		}
-		if b1 == '\u0000' && b2 == '\u0000' {
-			os.Exit(0)
-		}
+	if *checksum != "" {
+		os.Exit(checksumMode([2]string{fnames[0], fnames[1]}, offset, *checksum, *blockSize))
 	}
+
+	os.Exit(twoFileMode([2]string{fnames[0], fnames[1]}, offset))
 }
@@ -0,0 +1,36 @@
+// Copyright 2013 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import "testing"
+
+func TestBytesEqual(t *testing.T) {
+	for _, tt := range []struct {
+		a, b []byte
+		want bool
+	}{
+		{[]byte("abc"), []byte("abc"), true},
+		{[]byte("abc"), []byte("abd"), false},
+		{[]byte("abc"), []byte("ab"), false},
+		{nil, nil, true},
+	} {
+		if got := bytesEqual(tt.a, tt.b); got != tt.want {
+			t.Errorf("bytesEqual(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestExcludeListSet(t *testing.T) {
+	var e excludeList
+	if err := e.Set("*.log"); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Set("build/*"); err != nil {
+		t.Fatal(err)
+	}
+	if len(e) != 2 || e[0] != "*.log" || e[1] != "build/*" {
+		t.Errorf("excludeList after Set calls = %v", []string(e))
+	}
+}
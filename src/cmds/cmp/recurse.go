@@ -0,0 +1,263 @@
+// Copyright 2013 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/u-root/u-root/src/pkg/cmpcore"
+	"golang.org/x/sync/semaphore"
+)
+
+// treeDiff describes how a single relative path differs between two trees.
+type treeDiff struct {
+	rel    string
+	reason string
+}
+
+// listTree walks root and returns the set of relative paths it contains,
+// including directories (so a directory present on only one side, empty or
+// not, is still reported), skipping any path whose base name or path
+// relative to root matches one of the exclude globs.
+func listTree(root string) (map[string]os.FileInfo, error) {
+	files := map[string]os.FileInfo{}
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if path == root {
+			return nil
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		if excluded(rel) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		files[rel] = info
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return files, nil
+}
+
+func excluded(rel string) bool {
+	for _, pat := range exclude {
+		if ok, _ := filepath.Match(pat, rel); ok {
+			return true
+		}
+		if ok, _ := filepath.Match(pat, filepath.Base(rel)); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// pruneOneSidedChildren drops every rel that lies beneath a directory
+// present on only one side, so a one-sided directory is reported once
+// (e.g. "onlyA: only in dirA") instead of once per file it contains.
+func pruneOneSidedChildren(rels []string, filesA, filesB map[string]os.FileInfo) []string {
+	oneSidedDirs := map[string]bool{}
+	for _, rel := range rels {
+		ia, ib := filesA[rel], filesB[rel]
+		if (ia == nil) == (ib == nil) {
+			continue
+		}
+		info := ia
+		if info == nil {
+			info = ib
+		}
+		if info.IsDir() {
+			oneSidedDirs[rel] = true
+		}
+	}
+	if len(oneSidedDirs) == 0 {
+		return rels
+	}
+
+	var pruned []string
+	for _, rel := range rels {
+		if underOneSidedDir(rel, oneSidedDirs) {
+			continue
+		}
+		pruned = append(pruned, rel)
+	}
+	return pruned
+}
+
+// underOneSidedDir reports whether rel lies beneath one of the directories
+// in dirs, regardless of where in sorted order the two paths happen to fall
+// (e.g. a sibling like "onlyA.bak" can sort between "onlyA" and
+// "onlyA/child" since '.' < '/').
+func underOneSidedDir(rel string, dirs map[string]bool) bool {
+	for dir := range dirs {
+		if rel != dir && strings.HasPrefix(rel, dir+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+// compareEntry compares a single relative path present in tree a, tree b,
+// or both, returning a non-empty reason if they differ.
+func compareEntry(dirA, dirB, rel string, ia, ib os.FileInfo) (string, error) {
+	if ia == nil {
+		return "only in " + dirB, nil
+	}
+	if ib == nil {
+		return "only in " + dirA, nil
+	}
+
+	modeA, modeB := ia.Mode(), ib.Mode()
+	if modeA.Type() != modeB.Type() {
+		return fmt.Sprintf("type differs: %v vs %v", modeA.Type(), modeB.Type()), nil
+	}
+	if modeA.IsDir() {
+		return "", nil
+	}
+
+	if modeA&os.ModeSymlink != 0 {
+		targetA, err := os.Readlink(filepath.Join(dirA, rel))
+		if err != nil {
+			return "", err
+		}
+		targetB, err := os.Readlink(filepath.Join(dirB, rel))
+		if err != nil {
+			return "", err
+		}
+		if targetA != targetB {
+			return fmt.Sprintf("symlink target differs: %s vs %s", targetA, targetB), nil
+		}
+		return "", nil
+	}
+
+	if modeA.Perm() != modeB.Perm() {
+		return fmt.Sprintf("mode differs: %v vs %v", modeA.Perm(), modeB.Perm()), nil
+	}
+	if ia.Size() != ib.Size() {
+		return fmt.Sprintf("size differs: %d vs %d", ia.Size(), ib.Size()), nil
+	}
+
+	fa, err := os.Open(filepath.Join(dirA, rel))
+	if err != nil {
+		return "", err
+	}
+	defer fa.Close()
+	fb, err := os.Open(filepath.Join(dirB, rel))
+	if err != nil {
+		return "", err
+	}
+	defer fb.Close()
+
+	r, err := cmpcore.Compare(fa, fb, 0, 0, cmpcore.Options{})
+	if err != nil {
+		return "", err
+	}
+	if r.Equal {
+		return "", nil
+	}
+	if *brief {
+		return "contents differ", nil
+	}
+	return fmt.Sprintf("contents differ: char %d", r.First.CharNo), nil
+}
+
+// recurseMode implements -r: it walks dirA and dirB in parallel, bounded by
+// a weighted semaphore sized to GOMAXPROCS (the same pattern gofmt uses for
+// its concurrent formatting workers), and reports every path that differs.
+func recurseMode(dirA, dirB string) int {
+	filesA, err := listTree(dirA)
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+	filesB, err := listTree(dirB)
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+
+	seen := map[string]bool{}
+	var rels []string
+	for rel := range filesA {
+		rels = append(rels, rel)
+		seen[rel] = true
+	}
+	for rel := range filesB {
+		if !seen[rel] {
+			rels = append(rels, rel)
+		}
+	}
+	sort.Strings(rels)
+	rels = pruneOneSidedChildren(rels, filesA, filesB)
+
+	sem := semaphore.NewWeighted(int64(runtime.GOMAXPROCS(0)))
+	ctx := context.Background()
+
+	var mu sync.Mutex
+	var diffs []treeDiff
+	var wg sync.WaitGroup
+
+	for _, rel := range rels {
+		rel := rel
+		if err := sem.Acquire(ctx, 1); err != nil {
+			log.Fatalf("%v", err)
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer sem.Release(1)
+
+			reason, err := compareEntry(dirA, dirB, rel, filesA[rel], filesB[rel])
+			if err != nil {
+				mu.Lock()
+				diffs = append(diffs, treeDiff{rel: rel, reason: fmt.Sprintf("error: %v", err)})
+				mu.Unlock()
+				return
+			}
+			if reason == "" {
+				return
+			}
+			mu.Lock()
+			diffs = append(diffs, treeDiff{rel: rel, reason: reason})
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	if len(diffs) == 0 {
+		return 0
+	}
+	if *silent {
+		return 1
+	}
+
+	sort.Slice(diffs, func(i, j int) bool { return diffs[i].rel < diffs[j].rel })
+	for _, d := range diffs {
+		if *brief {
+			fmt.Println(d.rel)
+			continue
+		}
+		fmt.Printf("%s: %s\n", d.rel, d.reason)
+	}
+	return 1
+}
@@ -0,0 +1,178 @@
+// Copyright 2013 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+)
+
+func TestListTreeIncludesEmptyDir(t *testing.T) {
+	root := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, "sub", "empty"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "sub", "f.txt"), []byte("x"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	files, err := listTree(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, rel := range []string{"sub", filepath.Join("sub", "empty"), filepath.Join("sub", "f.txt")} {
+		if _, ok := files[rel]; !ok {
+			t.Errorf("listTree() missing %q", rel)
+		}
+	}
+}
+
+func TestRecurseModeIdenticalTrees(t *testing.T) {
+	dirA, dirB := t.TempDir(), t.TempDir()
+	for _, dir := range []string{dirA, dirB} {
+		if err := os.WriteFile(filepath.Join(dir, "f.txt"), []byte("x"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := recurseMode(dirA, dirB); got != 0 {
+		t.Errorf("recurseMode() = %d, want 0 for identical trees", got)
+	}
+}
+
+func TestRecurseModeOneSidedEmptyDir(t *testing.T) {
+	dirA, dirB := t.TempDir(), t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dirA, "onlyA"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dirA, "f.txt"), []byte("x"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dirB, "f.txt"), []byte("x"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if got := recurseMode(dirA, dirB); got != 1 {
+		t.Errorf("recurseMode() = %d, want 1 for a one-sided empty directory", got)
+	}
+}
+
+func TestRecurseModeOneSidedNonEmptyDirReportedOnce(t *testing.T) {
+	dirA, dirB := t.TempDir(), t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dirA, "onlyA", "sub"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dirA, "onlyA", "a.txt"), []byte("x"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dirA, "onlyA", "sub", "b.txt"), []byte("x"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dirA, "f.txt"), []byte("x"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dirB, "f.txt"), []byte("x"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	filesA, err := listTree(dirA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	filesB, err := listTree(dirB)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rels []string
+	for rel := range filesA {
+		rels = append(rels, rel)
+	}
+	sort.Strings(rels)
+	pruned := pruneOneSidedChildren(rels, filesA, filesB)
+
+	if len(pruned) != 2 {
+		t.Fatalf("pruneOneSidedChildren() = %v, want just [\"f.txt\", \"onlyA\"]", pruned)
+	}
+	for _, rel := range pruned {
+		if rel != "f.txt" && rel != "onlyA" {
+			t.Errorf("pruneOneSidedChildren() kept nested child %q, want it pruned", rel)
+		}
+	}
+}
+
+// TestPruneOneSidedChildrenSiblingInterleaving covers a sibling path, like
+// "onlyA.bak", that sorts between "onlyA" and "onlyA/child" (since '.' <
+// '/'), to make sure it doesn't stop a stateful scan from pruning the rest
+// of "onlyA"'s descendants.
+func TestPruneOneSidedChildrenSiblingInterleaving(t *testing.T) {
+	rels := []string{"onlyA", "onlyA.bak", filepath.Join("onlyA", "child")}
+	dirInfo := &fakeDirInfo{}
+	filesA := map[string]os.FileInfo{
+		"onlyA":                         dirInfo,
+		"onlyA.bak":                     dirInfo,
+		filepath.Join("onlyA", "child"): dirInfo,
+	}
+	filesB := map[string]os.FileInfo{
+		"onlyA.bak": dirInfo,
+	}
+
+	got := pruneOneSidedChildren(rels, filesA, filesB)
+	if len(got) != 2 || got[0] != "onlyA" || got[1] != "onlyA.bak" {
+		t.Fatalf("pruneOneSidedChildren() = %v, want [\"onlyA\", \"onlyA.bak\"]", got)
+	}
+}
+
+// fakeDirInfo is a minimal os.FileInfo that reports itself as a directory,
+// for tests that only care about IsDir().
+type fakeDirInfo struct{ os.FileInfo }
+
+func (fakeDirInfo) IsDir() bool { return true }
+
+func TestRecurseModeExcludedPattern(t *testing.T) {
+	dirA, dirB := t.TempDir(), t.TempDir()
+	if err := os.WriteFile(filepath.Join(dirA, "ignored.log"), []byte("a"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dirA, "keep.txt"), []byte("x"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dirB, "keep.txt"), []byte("x"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	old := exclude
+	exclude = excludeList{"*.log"}
+	defer func() { exclude = old }()
+
+	if got := recurseMode(dirA, dirB); got != 0 {
+		t.Errorf("recurseMode() = %d, want 0 with ignored.log excluded", got)
+	}
+}
+
+func TestRecurseModeModeDiffers(t *testing.T) {
+	dirA, dirB := t.TempDir(), t.TempDir()
+	if err := os.WriteFile(filepath.Join(dirA, "f.txt"), []byte("x"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dirB, "f.txt"), []byte("x"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if got := recurseMode(dirA, dirB); got != 1 {
+		t.Errorf("recurseMode() = %d, want 1 for mismatched mode", got)
+	}
+}
+
+func TestRecurseModeSymlinkTargetDiffers(t *testing.T) {
+	dirA, dirB := t.TempDir(), t.TempDir()
+	if err := os.Symlink("a-target", filepath.Join(dirA, "link")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("b-target", filepath.Join(dirB, "link")); err != nil {
+		t.Fatal(err)
+	}
+	if got := recurseMode(dirA, dirB); got != 1 {
+		t.Errorf("recurseMode() = %d, want 1 for differing symlink targets", got)
+	}
+}
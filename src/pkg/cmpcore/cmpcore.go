@@ -0,0 +1,122 @@
+// Copyright 2013 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package cmpcore implements the byte-stream comparison at the heart of
+// cmp, as a reusable library API shared by cmp's single-file mode, its
+// recursive directory-tree mode, and other u-root commands that need to
+// compare two streams.
+package cmpcore
+
+import (
+	"bufio"
+	"io"
+)
+
+// Options controls how Compare reports a difference.
+type Options struct {
+	// Line, if true, asks Compare to also count newlines so Diff.LineNo
+	// is meaningful.
+	Line bool
+	// All, if true, asks Compare to keep reading after the first
+	// difference and record every differing byte offset, instead of
+	// stopping at the first one.
+	All bool
+}
+
+// Diff describes a single differing byte.
+type Diff struct {
+	// CharNo is the 1-based byte offset of the difference.
+	CharNo int64
+	// LineNo is the 1-based line number containing CharNo, if
+	// Options.Line was set. Zero otherwise.
+	LineNo int64
+	// ValA and ValB are the differing bytes at CharNo.
+	ValA, ValB byte
+}
+
+// Report is the outcome of comparing two streams.
+type Report struct {
+	// Equal is true if a and b were identical.
+	Equal bool
+	// First is the first difference found, or the zero Diff if Equal.
+	First Diff
+	// ShortSide, if non-zero, indicates that one side reached EOF
+	// before the other: 1 means a was shorter, 2 means b was shorter.
+	// First is still valid, giving the offset at which the shorter
+	// stream ran out.
+	ShortSide int
+	// All holds every differing byte found, in order, if Options.All was
+	// set. It stops growing once one side reaches EOF.
+	All []Diff
+}
+
+// Compare seeks a and b to aOff and bOff respectively, then reads them in
+// lockstep and reports the first byte at which they differ, or an EOF
+// mismatch if one stream ends before the other. With Options.All it keeps
+// going and records every difference instead of stopping at the first.
+func Compare(a, b io.ReadSeeker, aOff, bOff int64, opts Options) (*Report, error) {
+	if aOff > 0 {
+		if _, err := a.Seek(aOff, io.SeekStart); err != nil {
+			return nil, err
+		}
+	}
+	if bOff > 0 {
+		if _, err := b.Seek(bOff, io.SeekStart); err != nil {
+			return nil, err
+		}
+	}
+
+	ra := bufio.NewReader(a)
+	rb := bufio.NewReader(b)
+
+	report := &Report{Equal: true}
+	charno, lineno := int64(1), int64(1)
+	for {
+		ba, erra := ra.ReadByte()
+		bb, errb := rb.ReadByte()
+
+		aDone := erra == io.EOF
+		bDone := errb == io.EOF
+		if erra != nil && !aDone {
+			return nil, erra
+		}
+		if errb != nil && !bDone {
+			return nil, errb
+		}
+
+		if aDone || bDone {
+			if aDone && bDone {
+				return report, nil
+			}
+			short := 1
+			if bDone {
+				short = 2
+			}
+			report.Equal = false
+			report.ShortSide = short
+			if report.First == (Diff{}) {
+				report.First = Diff{CharNo: charno, LineNo: lineno}
+			}
+			return report, nil
+		}
+
+		if ba != bb {
+			d := Diff{CharNo: charno, LineNo: lineno, ValA: ba, ValB: bb}
+			if report.Equal {
+				report.Equal = false
+				report.First = d
+			}
+			if opts.All {
+				report.All = append(report.All, d)
+			} else {
+				return report, nil
+			}
+		}
+
+		charno++
+		if opts.Line && ba == '\n' {
+			lineno++
+		}
+	}
+}
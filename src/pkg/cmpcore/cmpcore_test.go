@@ -0,0 +1,63 @@
+// Copyright 2013 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmpcore
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCompareIdentical(t *testing.T) {
+	r, err := Compare(strings.NewReader("hello"), strings.NewReader("hello"), 0, 0, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !r.Equal {
+		t.Errorf("Compare() = %+v, want Equal", r)
+	}
+}
+
+func TestCompareFirstDifference(t *testing.T) {
+	r, err := Compare(strings.NewReader("hello"), strings.NewReader("hXllo"), 0, 0, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Equal || r.First.CharNo != 2 {
+		t.Errorf("Compare() = %+v, want First.CharNo 2", r)
+	}
+}
+
+func TestCompareLineNo(t *testing.T) {
+	r, err := Compare(strings.NewReader("one\ntwo\nthree"), strings.NewReader("one\ntXo\nthree"), 0, 0, Options{Line: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Equal || r.First.LineNo != 2 {
+		t.Errorf("Compare() = %+v, want First.LineNo 2", r)
+	}
+}
+
+func TestCompareDifferentLengths(t *testing.T) {
+	r, err := Compare(strings.NewReader("short"), strings.NewReader("shorter"), 0, 0, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Equal || r.ShortSide != 1 {
+		t.Errorf("Compare() = %+v, want ShortSide 1", r)
+	}
+}
+
+func TestCompareAllDifferences(t *testing.T) {
+	r, err := Compare(strings.NewReader("aXaXa"), strings.NewReader("aYaYa"), 0, 0, Options{All: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Equal || len(r.All) != 2 {
+		t.Fatalf("Compare() = %+v, want 2 recorded differences", r)
+	}
+	if r.All[0].CharNo != 2 || r.All[1].CharNo != 4 {
+		t.Errorf("Compare() All = %+v, want CharNo 2 and 4", r.All)
+	}
+}
@@ -0,0 +1,459 @@
+// Copyright 2013 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package cmpdiff renders the differences between two byte streams as
+// either a unified text diff or a side-by-side hexdump, for use by cmp's
+// -d flag.
+package cmpdiff
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// Op describes the kind of a single line edit.
+type Op int
+
+// The kinds of line edits produced by Lines.
+const (
+	OpEqual Op = iota
+	OpDelete
+	OpInsert
+)
+
+// Edit is one line of an LCS-based line diff.
+type Edit struct {
+	Op   Op
+	Line string
+
+	// NoNewline marks that Line, as it appears in its original file, has
+	// no trailing newline, so Unified should annotate it the way diff(1)
+	// does with "\ No newline at end of file".
+	NoNewline bool
+}
+
+// sniffLen is the number of leading bytes scanned by IsBinary, matching the
+// convention used by git and diff(1).
+const sniffLen = 8192
+
+// IsBinary reports whether data looks like binary content, i.e. whether it
+// contains a NUL byte within the first 8 KiB.
+func IsBinary(data []byte) bool {
+	if len(data) > sniffLen {
+		data = data[:sniffLen]
+	}
+	return bytes.IndexByte(data, 0) >= 0
+}
+
+// splitLines splits s into lines, keeping the trailing newline (if any) off
+// each line. It also reports whether s is non-empty and lacks a trailing
+// newline, the condition diff(1) flags with "\ No newline at end of file".
+func splitLines(s string) ([]string, bool) {
+	if s == "" {
+		return nil, false
+	}
+	lines := strings.Split(s, "\n")
+	noTrailingNewline := lines[len(lines)-1] != ""
+	if !noTrailingNewline {
+		lines = lines[:len(lines)-1]
+	}
+	return lines, noTrailingNewline
+}
+
+// directDiffLimit bounds the size of the full LCS table lcsTable is allowed
+// to allocate. Above it, Lines falls back to the linear-space Hirschberg
+// algorithm instead of paying O(n·m) memory for the table.
+const directDiffLimit = 1 << 18
+
+// Lines computes a line-oriented diff between a and b using the
+// longest-common-subsequence algorithm and returns the edit script that
+// turns a into b. Large inputs are handled by Hirschberg's linear-space
+// technique (recursively splitting the longer side and locating the
+// matching split point in the other side via a pair of O(min(n,m))-space
+// scoring passes) so memory stays bounded even on inputs with tens of
+// thousands of lines; the classic O(n·m) table is only used directly on
+// small subproblems.
+func Lines(a, b []string) []Edit {
+	switch {
+	case len(a) == 0:
+		return insertAll(b)
+	case len(b) == 0:
+		return deleteAll(a)
+	}
+	if int64(len(a))*int64(len(b)) <= directDiffLimit {
+		return lcsTable(a, b)
+	}
+
+	if len(a) >= len(b) {
+		mid := len(a) / 2
+		split := bestSplit(scorePrefix(a[:mid], b), scoreSuffix(a[mid:], b))
+		return append(Lines(a[:mid], b[:split]), Lines(a[mid:], b[split:])...)
+	}
+	mid := len(b) / 2
+	split := bestSplit(scorePrefix(b[:mid], a), scoreSuffix(b[mid:], a))
+	return append(Lines(a[:split], b[:mid]), Lines(a[split:], b[mid:])...)
+}
+
+func insertAll(b []string) []Edit {
+	edits := make([]Edit, len(b))
+	for j, l := range b {
+		edits[j] = Edit{Op: OpInsert, Line: l}
+	}
+	return edits
+}
+
+func deleteAll(a []string) []Edit {
+	edits := make([]Edit, len(a))
+	for i, l := range a {
+		edits[i] = Edit{Op: OpDelete, Line: l}
+	}
+	return edits
+}
+
+// scorePrefix returns, for each j in 0..len(y), the length of the LCS of x
+// (in full) and y[:j], computed with a rolling two-row DP in O(len(y))
+// space.
+func scorePrefix(x, y []string) []int {
+	prev := make([]int, len(y)+1)
+	curr := make([]int, len(y)+1)
+	for i := 1; i <= len(x); i++ {
+		for j := 1; j <= len(y); j++ {
+			switch {
+			case x[i-1] == y[j-1]:
+				curr[j] = prev[j-1] + 1
+			case prev[j] >= curr[j-1]:
+				curr[j] = prev[j]
+			default:
+				curr[j] = curr[j-1]
+			}
+		}
+		prev, curr = curr, prev
+	}
+	return prev
+}
+
+// scoreSuffix returns, for each j in 0..len(y), the length of the LCS of x
+// (in full) and y[j:], computed with the same rolling DP as scorePrefix but
+// walking both sequences from their ends.
+func scoreSuffix(x, y []string) []int {
+	n, m := len(x), len(y)
+	prev := make([]int, m+1)
+	curr := make([]int, m+1)
+	for i := 1; i <= n; i++ {
+		for k := 1; k <= m; k++ {
+			switch {
+			case x[n-i] == y[m-k]:
+				curr[k] = prev[k-1] + 1
+			case prev[k] >= curr[k-1]:
+				curr[k] = prev[k]
+			default:
+				curr[k] = curr[k-1]
+			}
+		}
+		prev, curr = curr, prev
+	}
+	// prev[k] is now the LCS of x and y's last k elements; re-index by the
+	// split point j = m-k so it lines up with scorePrefix's j.
+	score := make([]int, m+1)
+	for j := 0; j <= m; j++ {
+		score[j] = prev[m-j]
+	}
+	return score
+}
+
+// bestSplit picks the split point j that maximizes left[j]+right[j], i.e.
+// the split of y that yields the longest combined LCS across both halves
+// of x.
+func bestSplit(left, right []int) int {
+	best, bestScore := 0, left[0]+right[0]
+	for j := 1; j < len(left); j++ {
+		if s := left[j] + right[j]; s > bestScore {
+			bestScore, best = s, j
+		}
+	}
+	return best
+}
+
+// lcsTable computes a line-oriented diff between a and b by building the
+// full (n+1)x(m+1) LCS table. It is only safe to call directly on inputs
+// small enough that the O(n·m) table is cheap; Lines uses it as the base
+// case for Hirschberg's recursive split.
+func lcsTable(a, b []string) []Edit {
+	n, m := len(a), len(b)
+
+	// lcs[i][j] is the length of the LCS of a[i:] and b[j:].
+	lcs := make([][]int, n+1)
+	for i := range lcs {
+		lcs[i] = make([]int, m+1)
+	}
+	for i := n - 1; i >= 0; i-- {
+		for j := m - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	var edits []Edit
+	i, j := 0, 0
+	for i < n && j < m {
+		switch {
+		case a[i] == b[j]:
+			edits = append(edits, Edit{Op: OpEqual, Line: a[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			edits = append(edits, Edit{Op: OpDelete, Line: a[i]})
+			i++
+		default:
+			edits = append(edits, Edit{Op: OpInsert, Line: b[j]})
+			j++
+		}
+	}
+	for ; i < n; i++ {
+		edits = append(edits, Edit{Op: OpDelete, Line: a[i]})
+	}
+	for ; j < m; j++ {
+		edits = append(edits, Edit{Op: OpInsert, Line: b[j]})
+	}
+	return edits
+}
+
+// hunk is a contiguous run of edits plus the surrounding context, tracked in
+// terms of 1-based line numbers in a and b.
+type hunk struct {
+	aStart, bStart int
+	edits          []Edit
+}
+
+// Unified renders edits (as produced by Lines) as a unified diff with the
+// given file names and context lines around each changed region.
+func Unified(aName, bName string, edits []Edit, context int) string {
+	hunks := groupHunks(edits, context)
+	if len(hunks) == 0 {
+		return ""
+	}
+
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "--- %s\n", aName)
+	fmt.Fprintf(&out, "+++ %s\n", bName)
+	for _, h := range hunks {
+		aLen, bLen := 0, 0
+		for _, e := range h.edits {
+			switch e.Op {
+			case OpEqual:
+				aLen++
+				bLen++
+			case OpDelete:
+				aLen++
+			case OpInsert:
+				bLen++
+			}
+		}
+		fmt.Fprintf(&out, "@@ -%d,%d +%d,%d @@\n", h.aStart, aLen, h.bStart, bLen)
+		for _, e := range h.edits {
+			switch e.Op {
+			case OpEqual:
+				fmt.Fprintf(&out, " %s\n", e.Line)
+			case OpDelete:
+				fmt.Fprintf(&out, "-%s\n", e.Line)
+			case OpInsert:
+				fmt.Fprintf(&out, "+%s\n", e.Line)
+			}
+			if e.NoNewline {
+				fmt.Fprintln(&out, `\ No newline at end of file`)
+			}
+		}
+	}
+	return out.String()
+}
+
+// groupHunks splits edits into hunks, each keeping up to context lines of
+// surrounding OpEqual lines, and records the 1-based starting line numbers.
+func groupHunks(edits []Edit, context int) []hunk {
+	var hunks []hunk
+	aLine, bLine := 1, 1
+
+	i := 0
+	for i < len(edits) {
+		if edits[i].Op == OpEqual {
+			aLine++
+			bLine++
+			i++
+			continue
+		}
+
+		// Start of a changed region: back up by up to context equal lines.
+		start := i
+		leading := 0
+		for start > 0 && edits[start-1].Op == OpEqual && leading < context {
+			start--
+			leading++
+		}
+
+		end := i
+		for end < len(edits) {
+			if edits[end].Op == OpEqual {
+				// Look ahead: if we hit another change within 2*context, keep going.
+				run := 0
+				k := end
+				for k < len(edits) && edits[k].Op == OpEqual && run < 2*context {
+					k++
+					run++
+				}
+				if k == len(edits) || edits[k].Op == OpEqual {
+					break
+				}
+				end = k
+				continue
+			}
+			end++
+		}
+		trailing := 0
+		for end < len(edits) && edits[end].Op == OpEqual && trailing < context {
+			end++
+			trailing++
+		}
+
+		hunks = append(hunks, hunk{
+			aStart: aLine - leading,
+			bStart: bLine - leading,
+			edits:  edits[start:end],
+		})
+
+		for ; i < end; i++ {
+			switch edits[i].Op {
+			case OpEqual:
+				aLine++
+				bLine++
+			case OpDelete:
+				aLine++
+			case OpInsert:
+				bLine++
+			}
+		}
+	}
+	return hunks
+}
+
+// hexRow renders one 16-byte hexdump -C row: the offset, hex bytes grouped
+// in two columns of eight, and the printable ASCII rendering.
+func hexRow(offset int, row []byte) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%08x ", offset)
+	for i := 0; i < 16; i++ {
+		if i == 8 {
+			b.WriteByte(' ')
+		}
+		if i < len(row) {
+			fmt.Fprintf(&b, "%02x ", row[i])
+		} else {
+			b.WriteString("   ")
+		}
+	}
+	b.WriteString(" |")
+	for _, c := range row {
+		if c >= 0x20 && c < 0x7f {
+			b.WriteByte(c)
+		} else {
+			b.WriteByte('.')
+		}
+	}
+	b.WriteString("|")
+	return b.String()
+}
+
+// HexDiff renders a side-by-side hexdump -C style diff of a and b: only
+// 16-byte rows that differ between the two are printed, with a's row
+// prefixed by "<" and b's row prefixed by ">".
+func HexDiff(a, b []byte) string {
+	var out bytes.Buffer
+	n := len(a)
+	if len(b) > n {
+		n = len(b)
+	}
+	for off := 0; off < n; off += 16 {
+		end := off + 16
+		var aRow, bRow []byte
+		if off < len(a) {
+			aRow = a[off:min(end, len(a))]
+		}
+		if off < len(b) {
+			bRow = b[off:min(end, len(b))]
+		}
+		if bytes.Equal(aRow, bRow) {
+			continue
+		}
+		fmt.Fprintf(&out, "< %s\n", hexRow(off, aRow))
+		fmt.Fprintf(&out, "> %s\n", hexRow(off, bRow))
+	}
+	return out.String()
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// TextDiff is a convenience wrapper that splits a and b into lines, diffs
+// them, and renders the result as a unified diff.
+func TextDiff(aName, bName string, a, b []byte) string {
+	linesA, noNLA := splitLines(string(a))
+	linesB, noNLB := splitLines(string(b))
+	edits := annotateNoNewline(Lines(linesA, linesB), noNLA, noNLB)
+	return Unified(aName, bName, edits, 3)
+}
+
+// annotateNoNewline marks the edits representing the last line of a and the
+// last line of b with NoNewline, per noNLA and noNLB. If the two files have
+// identical lines but differ only in whether their final line is
+// newline-terminated, the single OpEqual edit covering that line is split
+// into a delete/insert pair so each side's newline status can be reported
+// independently, matching how diff(1) treats this case.
+func annotateNoNewline(edits []Edit, noNLA, noNLB bool) []Edit {
+	if len(edits) == 0 || (!noNLA && !noNLB) {
+		return edits
+	}
+
+	lastA, lastB := -1, -1
+	for i, e := range edits {
+		if e.Op != OpInsert {
+			lastA = i
+		}
+		if e.Op != OpDelete {
+			lastB = i
+		}
+	}
+
+	if lastA == lastB {
+		if noNLA == noNLB {
+			edits[lastA].NoNewline = true
+			return edits
+		}
+		e := edits[lastA]
+		split := []Edit{
+			{Op: OpDelete, Line: e.Line, NoNewline: noNLA},
+			{Op: OpInsert, Line: e.Line, NoNewline: noNLB},
+		}
+		out := append([]Edit{}, edits[:lastA]...)
+		out = append(out, split...)
+		out = append(out, edits[lastA+1:]...)
+		return out
+	}
+
+	if noNLA && lastA >= 0 {
+		edits[lastA].NoNewline = true
+	}
+	if noNLB && lastB >= 0 {
+		edits[lastB].NoNewline = true
+	}
+	return edits
+}
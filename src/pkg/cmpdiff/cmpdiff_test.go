@@ -0,0 +1,142 @@
+// Copyright 2013 the u-root Authors. All rights reserved
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cmpdiff
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestIsBinary(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		data []byte
+		want bool
+	}{
+		{"empty", nil, false},
+		{"text", []byte("hello\nworld\n"), false},
+		{"nul", []byte("hello\x00world"), true},
+	} {
+		if got := IsBinary(tt.data); got != tt.want {
+			t.Errorf("%s: IsBinary() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestTextDiffIdentical(t *testing.T) {
+	a := []byte("one\ntwo\nthree\n")
+	if got := TextDiff("a", "b", a, a); got != "" {
+		t.Errorf("identical files produced a diff: %q", got)
+	}
+}
+
+func TestTextDiffOneLine(t *testing.T) {
+	a := []byte("one\ntwo\nthree\n")
+	b := []byte("one\nTWO\nthree\n")
+	got := TextDiff("a", "b", a, b)
+	if !strings.Contains(got, "--- a\n") || !strings.Contains(got, "+++ b\n") {
+		t.Fatalf("missing file headers: %q", got)
+	}
+	if !strings.Contains(got, "-two\n") || !strings.Contains(got, "+TWO\n") {
+		t.Errorf("missing changed lines: %q", got)
+	}
+}
+
+func TestTextDiffDifferentLengths(t *testing.T) {
+	a := []byte("one\ntwo\n")
+	b := []byte("one\ntwo\nthree\nfour\n")
+	got := TextDiff("a", "b", a, b)
+	if !strings.Contains(got, "+three\n") || !strings.Contains(got, "+four\n") {
+		t.Errorf("missing appended lines: %q", got)
+	}
+}
+
+func TestTextDiffMissingTrailingNewline(t *testing.T) {
+	a := []byte("x\ny")
+	b := []byte("x\ny\n")
+	got := TextDiff("a", "b", a, b)
+	if !strings.Contains(got, "-y\n\\ No newline at end of file\n+y\n") {
+		t.Errorf("missing no-newline annotation: %q", got)
+	}
+}
+
+func TestHexDiffIdentical(t *testing.T) {
+	a := []byte("0123456789abcdef0123456789abcdef")
+	if got := HexDiff(a, a); got != "" {
+		t.Errorf("identical buffers produced a hex diff: %q", got)
+	}
+}
+
+func TestHexDiffOneByte(t *testing.T) {
+	a := []byte("0123456789abcdef")
+	b := []byte("0123456789abcdeg")
+	got := HexDiff(a, b)
+	if !strings.HasPrefix(got, "< 00000000 ") {
+		t.Fatalf("missing '<' row: %q", got)
+	}
+	if !strings.Contains(got, "> 00000000 ") {
+		t.Fatalf("missing '>' row: %q", got)
+	}
+}
+
+func TestHexDiffEmbeddedNUL(t *testing.T) {
+	a := []byte("ab\x00cd")
+	b := []byte("ab\x00ce")
+	if !IsBinary(a) {
+		t.Fatalf("expected a to be detected as binary")
+	}
+	got := HexDiff(a, b)
+	if got == "" {
+		t.Fatalf("expected a hex diff for differing NUL-containing buffers")
+	}
+}
+
+// TestLinesLargeInputUsesLinearSpacePath exercises the Hirschberg
+// recursion in Lines (rather than the direct lcsTable base case) by diffing
+// two inputs whose product exceeds directDiffLimit, and checks the result
+// is still the expected minimal edit script.
+func TestLinesLargeInputUsesLinearSpacePath(t *testing.T) {
+	const n = 600 // 600*600 > directDiffLimit
+	a := make([]string, n)
+	b := make([]string, n)
+	for i := range a {
+		a[i] = fmt.Sprintf("line%d", i)
+		b[i] = a[i]
+	}
+	b[n/2] = "CHANGED"
+
+	edits := Lines(a, b)
+
+	var equal, deleted, inserted int
+	for _, e := range edits {
+		switch e.Op {
+		case OpEqual:
+			equal++
+		case OpDelete:
+			deleted++
+			if e.Line != a[n/2] {
+				t.Errorf("unexpected deleted line %q", e.Line)
+			}
+		case OpInsert:
+			inserted++
+			if e.Line != "CHANGED" {
+				t.Errorf("unexpected inserted line %q", e.Line)
+			}
+		}
+	}
+	if equal != n-1 || deleted != 1 || inserted != 1 {
+		t.Fatalf("Lines() = %d equal, %d deleted, %d inserted; want %d, 1, 1", equal, deleted, inserted, n-1)
+	}
+}
+
+func TestHexDiffDifferentLengths(t *testing.T) {
+	a := []byte("0123456789abcdef")
+	b := []byte("0123456789abcdef0123")
+	got := HexDiff(a, b)
+	if !strings.Contains(got, "< 00000010 ") {
+		t.Fatalf("missing row for a's short tail: %q", got)
+	}
+}